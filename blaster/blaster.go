@@ -0,0 +1,267 @@
+package blaster
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Worker sends one templated request and reports how it went. Each registered worker
+// type (dummy, http, gcs, ...) provides a constructor that turns its config section
+// into a Worker.
+type Worker interface {
+	Send(data map[string]string) error
+	Close() error
+}
+
+// WorkerConstructor builds a Worker from its parsed config section. stats is the run's
+// shared endpoint-stats pipeline, for workers that front several backend endpoints to
+// report per-endpoint counters into. rng is the run's seeded PRNG (see Blaster.SetSeed)
+// and must be used for any routing decision (e.g. random/weighted endpoint selection)
+// that should replay the same way the rest of a -seed run does.
+type WorkerConstructor func(config map[string]interface{}, stats *Stats, rng *rand.Rand) (Worker, error)
+
+// Config is the top-level shape of a blaster run file: which worker to drive and the
+// payload template to send it.
+type Config struct {
+	Type    string                 `json:"type"`
+	Worker  map[string]interface{} `json:"worker"`
+	Payload interface{}            `json:"payload"`
+
+	// Key, if set, is rendered once per request (the same way Payload is) and passed
+	// to Worker.Send as data["key"]. It's the routing key a multi-endpoint worker's
+	// sticky_by_key strategy hashes on; leave it unset if no worker in use needs one.
+	Key interface{} `json:"key"`
+
+	// DefaultWeights biases the plain (non-_weighted) pattern builtins, e.g.
+	// {"rand_address": {"addr1": 80, "stake1": 5, "*": 15}}, so a realistic query mix
+	// doesn't require rewriting every template to use the *_weighted builtins.
+	DefaultWeights map[string]map[string]float64 `json:"default_weights"`
+}
+
+// EndpointStats accumulates latency and error counts for one backend endpoint, so a
+// worker fronting several replicas (see httpworker/gcsworker) can report a per-endpoint
+// breakdown instead of one pooled total.
+type EndpointStats struct {
+	mu           sync.Mutex
+	Requests     uint64
+	Errors       uint64
+	TotalLatency time.Duration
+}
+
+// Record adds one request's outcome to the endpoint's counters.
+func (s *EndpointStats) Record(latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Requests++
+	s.TotalLatency += latency
+	if err != nil {
+		s.Errors++
+	}
+}
+
+// Snapshot returns a copy of the counters for printing a summary.
+func (s *EndpointStats) Snapshot() (requests, errs uint64, avgLatency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Requests == 0 {
+		return 0, 0, 0
+	}
+	return s.Requests, s.Errors, s.TotalLatency / time.Duration(s.Requests)
+}
+
+// Stats is the shared counters pipeline multi-endpoint workers report into; Blaster
+// prints it once a run completes so throughput can be broken down by backend.
+type Stats struct {
+	mu        sync.Mutex
+	endpoints map[string]*EndpointStats
+}
+
+// NewStats creates an empty Stats pipeline.
+func NewStats() *Stats {
+	return &Stats{endpoints: map[string]*EndpointStats{}}
+}
+
+// Endpoint returns the counters for name, creating them on first use.
+func (s *Stats) Endpoint(name string) *EndpointStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.endpoints[name]
+	if !ok {
+		e = &EndpointStats{}
+		s.endpoints[name] = e
+	}
+	return e
+}
+
+// Summary logs a one-line throughput/error breakdown per endpoint.
+func (s *Stats) Summary() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, e := range s.endpoints {
+		requests, errs, avgLatency := e.Snapshot()
+		log.Printf("endpoint %s: %d requests, %d errors, avg latency %s", name, requests, errs, avgLatency)
+	}
+}
+
+// Blaster is the load-testing harness: it owns the registered worker types, the run's
+// lifetime context, and the PRNG shared by every template builtin.
+type Blaster struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	workerTypes map[string]WorkerConstructor
+	stats       *Stats
+
+	seed [32]byte
+	rand *randSource
+}
+
+// New creates a Blaster bound to ctx. Call Exit (or cancel) to stop any in-flight run.
+func New(ctx context.Context, cancel context.CancelFunc) *Blaster {
+	return &Blaster{
+		ctx:         ctx,
+		cancel:      cancel,
+		workerTypes: map[string]WorkerConstructor{},
+		stats:       NewStats(),
+	}
+}
+
+// Stats returns the run's shared endpoint-stats pipeline, for workers that front
+// several backend endpoints to report per-endpoint counters into.
+func (b *Blaster) Stats() *Stats {
+	return b.stats
+}
+
+// Exit cancels the Blaster's context, stopping any in-flight run.
+func (b *Blaster) Exit() {
+	b.cancel()
+}
+
+// RegisterWorkerType makes a worker type available to the `type` field of a config file.
+func (b *Blaster) RegisterWorkerType(name string, ctor WorkerConstructor) {
+	b.workerTypes[name] = ctor
+}
+
+// SetSeed fixes the PRNG shared by every template builtin to seed, so a run can be
+// replayed bit-for-bit. Passing a zero seed draws a fresh one from crypto/rand.
+func (b *Blaster) SetSeed(seed [32]byte) {
+	if seed == ([32]byte{}) {
+		if _, err := cryptorand.Read(seed[:]); err != nil {
+			log.Fatalf("failed to generate a random seed: %s", err)
+		}
+	}
+	b.seed = seed
+	b.rand = &randSource{r: rand.New(rand.NewChaCha8(seed))}
+	log.Printf("using PRNG seed %s (pass -seed=%s to replay this run)", hex.EncodeToString(seed[:]), hex.EncodeToString(seed[:]))
+}
+
+var seedFlag = flag.String("seed", "", "hex-encoded 32-byte PRNG seed to replay a previous run (random if unset)")
+
+// Command runs the blaster CLI: it parses flags, loads the config file named by the
+// remaining argument, and drives the configured worker until the run completes or ctx
+// is cancelled.
+func (b *Blaster) Command(ctx context.Context) error {
+	flag.Parse()
+
+	var seed [32]byte
+	if *seedFlag != "" {
+		decoded, err := hex.DecodeString(*seedFlag)
+		if err != nil || len(decoded) != len(seed) {
+			return errors.Errorf("-seed must be a %d-byte hex string", len(seed))
+		}
+		copy(seed[:], decoded)
+	}
+	b.SetSeed(seed)
+
+	if flag.NArg() < 1 {
+		return errors.New("usage: blast-kupo [-seed=<hex>] <config.json>")
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	var cfg Config
+	if err := json.NewDecoder(f).Decode(&cfg); err != nil {
+		return errors.WithStack(err)
+	}
+
+	ctor, ok := b.workerTypes[cfg.Type]
+	if !ok {
+		return errors.Errorf("unknown worker type %q", cfg.Type)
+	}
+
+	worker, err := ctor(cfg.Worker, b.stats, b.rand.r)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer worker.Close()
+	defer b.stats.Summary()
+
+	b.rand.defaultWeights = cfg.DefaultWeights
+
+	payload, err := ParsePayload(cfg.Payload, b.rand)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var keyRenderer renderer
+	if cfg.Key != nil {
+		keyRenderer, err = parseRenderer(cfg.Key, b.rand)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	for ctx.Err() == nil {
+		data := map[string]string{}
+
+		if keyRenderer != nil {
+			renderedKey, err := keyRenderer.render(data)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if s, ok := renderedKey.(string); ok {
+				data["key"] = s
+			} else {
+				data["key"] = fmt.Sprint(renderedKey)
+			}
+		}
+
+		rendered, err := payload.render(data)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		// A cborR payload has already rendered to raw bytes; everything else renders
+		// to a JSON-shaped tree that still needs marshalling.
+		var body []byte
+		if raw, ok := rendered.([]byte); ok {
+			body = raw
+		} else {
+			body, err = json.Marshal(rendered)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		data["payload"] = string(body)
+
+		if err := worker.Send(data); err != nil {
+			log.Printf("send failed: %s", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package blaster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// byteStringSentinel prefixes the string a byte-producing builtin (rand_datum_cbor,
+// rand_tx_bytes, hex_to_bytes) returns. text/template's Execute always stringifies a
+// template action's result via fmt.Fprint, so a []byte return would print as its
+// decimal-array representation ("[1 2 3]") rather than the raw bytes; encoding the
+// bytes as hex behind this sentinel lets them survive templateR's render as a string,
+// and encodeCBOR decodes them back into a CBOR byte string (instead of a text string)
+// when it sees the prefix.
+const byteStringSentinel = "\x00blaster-bytes:"
+
+func encodeByteString(b []byte) string {
+	return byteStringSentinel + hex.EncodeToString(b)
+}
+
+// cborR renders the same parsed tree as mapR/sliceR/templateR/nativeR, but encodes the
+// rendered value as canonical CBOR (RFC 8949: definite lengths, shortest integer form,
+// map keys sorted by encoded-length then lexicographically) instead of the default
+// JSON-shaped output. It's selected by a top-level "encoding": "cbor" key — see
+// ParsePayload.
+type cborR struct {
+	inner renderer
+}
+
+func (c cborR) render(data map[string]string) (interface{}, error) {
+	v, err := c.inner.render(data)
+	if err != nil {
+		return nil, err
+	}
+	return encodeCBOR(v)
+}
+
+// encodeCBOR canonically encodes v, which must be built only from the types this
+// package's renderers ever produce: map[string]interface{}, []interface{}, string,
+// []byte, bool, nil, the integer kinds nativeR accepts, float32 and float64. Any other
+// type (including the complex kinds nativeR also accepts) is an error.
+func encodeCBOR(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return []byte{0xf6}, nil
+	case bool:
+		if v {
+			return []byte{0xf5}, nil
+		}
+		return []byte{0xf4}, nil
+	case []byte:
+		return encodeCBORHead(2, uint64(len(v)), v), nil
+	case string:
+		if raw, ok := strings.CutPrefix(v, byteStringSentinel); ok {
+			b, err := hex.DecodeString(raw)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cbor: invalid byte-string sentinel payload %q", v)
+			}
+			return encodeCBORHead(2, uint64(len(b)), b), nil
+		}
+		return encodeCBORHead(3, uint64(len(v)), []byte(v)), nil
+	case map[string]interface{}:
+		return encodeCBORMap(v)
+	case []interface{}:
+		buf := encodeCBORHead(4, uint64(len(v)), nil)
+		for _, elem := range v {
+			enc, err := encodeCBOR(elem)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, enc...)
+		}
+		return buf, nil
+	case float32:
+		return encodeCBORNumber(float64(v)), nil
+	case float64:
+		// encoding/json decodes every JSON number into float64, so a whole-valued
+		// float here almost always started life as an integer literal in the config
+		// (tx amounts, indices, datum integers); encode it as a canonical CBOR integer
+		// rather than an IEEE-754 double. Genuinely fractional values still float.
+		return encodeCBORNumber(v), nil
+	default:
+		n, ok := toInt64(v)
+		if !ok {
+			return nil, errors.Errorf("cbor: unsupported payload value of type %T", v)
+		}
+		if n < 0 {
+			return encodeCBORHead(1, uint64(-n-1), nil), nil
+		}
+		return encodeCBORHead(0, uint64(n), nil), nil
+	}
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	case uintptr:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// encodeCBORHead writes a major-type/argument head using the shortest definite-length
+// encoding RFC 8949 allows, followed by payload (nil for types with no payload here).
+func encodeCBORHead(major byte, arg uint64, payload []byte) []byte {
+	head := major << 5
+	var buf []byte
+	switch {
+	case arg < 24:
+		buf = []byte{head | byte(arg)}
+	case arg <= math.MaxUint8:
+		buf = []byte{head | 24, byte(arg)}
+	case arg <= math.MaxUint16:
+		buf = make([]byte, 3)
+		buf[0] = head | 25
+		binary.BigEndian.PutUint16(buf[1:], uint16(arg))
+	case arg <= math.MaxUint32:
+		buf = make([]byte, 5)
+		buf[0] = head | 26
+		binary.BigEndian.PutUint32(buf[1:], uint32(arg))
+	default:
+		buf = make([]byte, 9)
+		buf[0] = head | 27
+		binary.BigEndian.PutUint64(buf[1:], arg)
+	}
+	return append(buf, payload...)
+}
+
+func encodeCBORFloat(f float64) []byte {
+	buf := make([]byte, 9)
+	buf[0] = 0xfb // major type 7, additional info 27: IEEE 754 double
+	binary.BigEndian.PutUint64(buf[1:], math.Float64bits(f))
+	return buf
+}
+
+// encodeCBORNumber encodes a whole-valued, in-int64-range float as a canonical CBOR
+// integer (major type 0 or 1), and anything else as an IEEE-754 double.
+func encodeCBORNumber(f float64) []byte {
+	if !math.IsInf(f, 0) && !math.IsNaN(f) && f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+		n := int64(f)
+		if n < 0 {
+			return encodeCBORHead(1, uint64(-n-1), nil)
+		}
+		return encodeCBORHead(0, uint64(n), nil)
+	}
+	return encodeCBORFloat(f)
+}
+
+func encodeCBORMap(m map[string]interface{}) ([]byte, error) {
+	type entry struct {
+		key   []byte
+		value []byte
+	}
+	entries := make([]entry, 0, len(m))
+	for k, v := range m {
+		encKey, err := encodeCBOR(k)
+		if err != nil {
+			return nil, err
+		}
+		encValue, err := encodeCBOR(v)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key: encKey, value: encValue})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if len(entries[i].key) != len(entries[j].key) {
+			return len(entries[i].key) < len(entries[j].key)
+		}
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	buf := encodeCBORHead(5, uint64(len(entries)), nil)
+	for _, e := range entries {
+		buf = append(buf, e.key...)
+		buf = append(buf, e.value...)
+	}
+	return buf, nil
+}
+
+// randDatumCBOR returns random bytes standing in for a Plutus Data value's bytes field,
+// without modelling the full Plutus Data grammar. Like rand_tx_bytes and hex_to_bytes,
+// it returns a byteStringSentinel-prefixed string (see that const) so the bytes survive
+// template execution intact and encodeCBOR renders them as a CBOR byte string rather
+// than a text string.
+func (rs *randSource) randDatumCBOR() (interface{}, error) {
+	buf := make([]byte, 32)
+	rs.fillRandomBytes(buf)
+	return encodeByteString(buf), nil
+}
+
+// randTxBytes returns random bytes standing in for a serialized Cardano transaction,
+// for exercising submit-api/Ogmios payloads that expect tx bytes. See randDatumCBOR for
+// why it returns a sentinel-prefixed string instead of []byte.
+func (rs *randSource) randTxBytes() interface{} {
+	buf := make([]byte, 256)
+	rs.fillRandomBytes(buf)
+	return encodeByteString(buf)
+}
+
+// hexToBytes decodes a hex string into the payload's byte-string value, so a template
+// can inject a fixed binary blob (e.g. a known datum or tx) into a CBOR-encoded
+// payload. See randDatumCBOR for why it returns a sentinel-prefixed string instead of
+// []byte.
+func hexToBytes(s string) (interface{}, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "hex_to_bytes: %q", s)
+	}
+	return encodeByteString(b), nil
+}
@@ -0,0 +1,60 @@
+package blaster
+
+import (
+	"testing"
+)
+
+func TestEncodeCBORWholeValuedFloatIsInteger(t *testing.T) {
+	// encoding/json decodes every JSON number into float64; a whole-valued one (e.g.
+	// a tx amount or index) must still come out as a canonical CBOR integer.
+	got, err := encodeCBOR(float64(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x18, 42} // major type 0, one-byte argument
+	if string(got) != string(want) {
+		t.Fatalf("got % x, want % x", got, want)
+	}
+}
+
+func TestEncodeCBORFractionalFloatStaysFloat(t *testing.T) {
+	got, err := encodeCBOR(1.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != 0xfb {
+		t.Fatalf("expected an IEEE-754 double head (0xfb), got %#x", got[0])
+	}
+}
+
+func TestEncodeCBORByteStringSentinelDecodesToByteString(t *testing.T) {
+	got, err := encodeCBOR(encodeByteString([]byte{0xde, 0xad, 0xbe, 0xef}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x44, 0xde, 0xad, 0xbe, 0xef} // major type 2, length 4
+	if string(got) != string(want) {
+		t.Fatalf("got % x, want % x", got, want)
+	}
+}
+
+func TestEncodeCBORMapKeysSortedByLengthThenLex(t *testing.T) {
+	got, err := encodeCBOR(map[string]interface{}{
+		"bb": 1,
+		"a":  2,
+		"ab": 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Expect key order: "a" (len 1), then "ab", "bb" (len 2, lexicographic).
+	want := []byte{
+		0xa3,            // map(3)
+		0x61, 'a', 0x02, // "a": 2
+		0x62, 'a', 'b', 0x03, // "ab": 3
+		0x62, 'b', 'b', 0x01, // "bb": 1
+	}
+	if string(got) != string(want) {
+		t.Fatalf("got % x, want % x", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+package blaster
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Strategy selects which endpoint a multi-endpoint worker (httpworker, gcsworker, ...)
+// routes a request to. It's shared across worker types so they behave consistently.
+type Strategy string
+
+const (
+	// StrategyRoundRobin cycles through healthy endpoints in order.
+	StrategyRoundRobin Strategy = "round_robin"
+	// StrategyRandom picks a uniformly random healthy endpoint per request.
+	StrategyRandom Strategy = "random"
+	// StrategyWeighted picks a healthy endpoint biased by its configured Weight.
+	StrategyWeighted Strategy = "weighted"
+	// StrategyStickyByKey hashes the request's routing key to always route it to the
+	// same endpoint, as long as that endpoint stays healthy.
+	StrategyStickyByKey Strategy = "sticky_by_key"
+	// StrategyFailover always prefers the first healthy endpoint in config order.
+	StrategyFailover Strategy = "failover"
+)
+
+// Endpoint is one backend a Router can pick between. Name doubles as the key into
+// Stats.Endpoint, so it should be the worker's own identifier for the backend (a URL,
+// a bucket name, ...).
+type Endpoint struct {
+	Name   string
+	Weight float64
+
+	unhealthyUntil atomic.Int64 // UnixNano; zero or past means healthy
+}
+
+// NewEndpoint creates an Endpoint, defaulting weight to 1 when it's not positive.
+func NewEndpoint(name string, weight float64) *Endpoint {
+	if weight <= 0 {
+		weight = 1
+	}
+	return &Endpoint{Name: name, Weight: weight}
+}
+
+func (e *Endpoint) healthy(now time.Time) bool {
+	until := e.unhealthyUntil.Load()
+	return until == 0 || now.UnixNano() >= until
+}
+
+// MarkUnhealthy excludes e from Pick until cooldown has elapsed.
+func (e *Endpoint) MarkUnhealthy(now time.Time, cooldown time.Duration) {
+	e.unhealthyUntil.Store(now.Add(cooldown).UnixNano())
+}
+
+// Router picks an Endpoint per Strategy. Random/weighted picks use the run's seeded
+// PRNG (see Blaster.SetSeed), so which endpoint handles a given request replays the
+// same way the rest of a -seed run does.
+type Router struct {
+	Strategy  Strategy
+	Endpoints []*Endpoint
+
+	mu   sync.Mutex
+	next int // round-robin cursor
+}
+
+// Pick chooses an endpoint, preferring healthy ones but falling back to the full set if
+// every endpoint is currently in cooldown. Returns nil if there are no endpoints at all.
+func (r *Router) Pick(now time.Time, key string, rng *rand.Rand) *Endpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.Endpoints) == 0 {
+		return nil
+	}
+
+	candidates := make([]*Endpoint, 0, len(r.Endpoints))
+	for _, e := range r.Endpoints {
+		if e.healthy(now) {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = r.Endpoints
+	}
+
+	switch r.Strategy {
+	case StrategyFailover:
+		return candidates[0]
+	case StrategyRandom:
+		return candidates[rng.IntN(len(candidates))]
+	case StrategyWeighted:
+		return pickWeighted(candidates, rng)
+	case StrategyStickyByKey:
+		// Hash over every endpoint so the key's endpoint doesn't change when some other
+		// endpoint's health does; rehash among candidates only while it's in cooldown.
+		if e := r.Endpoints[hashKey(key)%len(r.Endpoints)]; e.healthy(now) {
+			return e
+		}
+		return candidates[hashKey(key)%len(candidates)]
+	case StrategyRoundRobin:
+		fallthrough
+	default:
+		e := candidates[r.next%len(candidates)]
+		r.next++
+		return e
+	}
+}
+
+func pickWeighted(endpoints []*Endpoint, rng *rand.Rand) *Endpoint {
+	total := 0.0
+	for _, e := range endpoints {
+		total += e.Weight
+	}
+	target := rng.Float64() * total
+	for _, e := range endpoints {
+		if target < e.Weight {
+			return e
+		}
+		target -= e.Weight
+	}
+	return endpoints[len(endpoints)-1]
+}
+
+func hashKey(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32())
+}
@@ -0,0 +1,86 @@
+package blaster
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+func TestRouterFailoverPrefersFirstHealthy(t *testing.T) {
+	a := NewEndpoint("a", 1)
+	b := NewEndpoint("b", 1)
+	r := &Router{Strategy: StrategyFailover, Endpoints: []*Endpoint{a, b}}
+
+	now := time.Unix(0, 0)
+	if got := r.Pick(now, "", nil); got != a {
+		t.Fatalf("expected endpoint a, got %v", got.Name)
+	}
+
+	a.MarkUnhealthy(now, time.Minute)
+	if got := r.Pick(now, "", nil); got != b {
+		t.Fatalf("expected endpoint b once a is unhealthy, got %v", got.Name)
+	}
+}
+
+func TestRouterRoundRobinCycles(t *testing.T) {
+	a := NewEndpoint("a", 1)
+	b := NewEndpoint("b", 1)
+	r := &Router{Strategy: StrategyRoundRobin, Endpoints: []*Endpoint{a, b}}
+
+	now := time.Unix(0, 0)
+	seen := []string{
+		r.Pick(now, "", nil).Name,
+		r.Pick(now, "", nil).Name,
+		r.Pick(now, "", nil).Name,
+	}
+	want := []string{"a", "b", "a"}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("pick %d: got %s, want %s", i, seen[i], want[i])
+		}
+	}
+}
+
+func TestRouterStickyByKeyIsDeterministic(t *testing.T) {
+	endpoints := []*Endpoint{NewEndpoint("a", 1), NewEndpoint("b", 1), NewEndpoint("c", 1)}
+	r := &Router{Strategy: StrategyStickyByKey, Endpoints: endpoints}
+
+	now := time.Unix(0, 0)
+	first := r.Pick(now, "addr1abc", nil)
+	for i := 0; i < 10; i++ {
+		if got := r.Pick(now, "addr1abc", nil); got != first {
+			t.Fatalf("sticky_by_key picked %s then %s for the same key", first.Name, got.Name)
+		}
+	}
+}
+
+func TestRouterWeightedIsReproducibleFromSeed(t *testing.T) {
+	newRouter := func() *Router {
+		return &Router{Strategy: StrategyWeighted, Endpoints: []*Endpoint{
+			NewEndpoint("a", 90),
+			NewEndpoint("b", 10),
+		}}
+	}
+
+	run := func(seed [32]byte) []string {
+		rng := rand.New(rand.NewChaCha8(seed))
+		r := newRouter()
+		now := time.Unix(0, 0)
+		picks := make([]string, 20)
+		for i := range picks {
+			picks[i] = r.Pick(now, "", rng).Name
+		}
+		return picks
+	}
+
+	var seed [32]byte
+	seed[0] = 7
+
+	first := run(seed)
+	second := run(seed)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("pick %d differs between runs with the same seed: %s vs %s", i, first[i], second[i])
+		}
+	}
+}
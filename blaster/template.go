@@ -3,158 +3,322 @@ package blaster
 import (
 	"bytes"
 	"fmt"
-	"log"
+	"math/rand/v2"
+	"strconv"
+	"strings"
 	"text/template"
 
-	"math/rand"
-	"time"
-
 	"eagain.net/go/bech32"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/blake2b"
 )
 
-var builtins = template.FuncMap{
-	"rand_int":          randInt,
-	"rand_string":       randString,
-	"rand_float":        randFloat,
-	"rand_datum_hash":   randDatumHash,
-	"rand_address":      randAddressPattern,
-	"rand_credential":   randCredentialPattern,
-	"rand_asset":        randAssetPattern,
-	"rand_output_ref":   randOutputReferencePattern,
-	"rand_metadata_tag": randMetadataTagPattern,
+// randSource is the small context struct threaded through every template builtin. It
+// carries the run's PRNG so builtins never touch global math/rand state: runs are
+// reproducible from a single seed (see Blaster.SetSeed) and concurrent workers don't
+// contend on the global lock.
+//
+// defaultWeights lets a config bias a plain builtin (e.g. rand_address) towards some
+// alternatives without every template spelling out the _weighted form by hand; it maps
+// builtin name -> alternative label -> weight, and is empty unless Config.DefaultWeights
+// was set.
+type randSource struct {
+	r              *rand.Rand
+	defaultWeights map[string]map[string]float64
 }
 
-func randInt(from int, to int) interface{} {
-	return rand.Intn(to-from) + from
+func newBuiltins(rs *randSource) template.FuncMap {
+	return template.FuncMap{
+		"rand_int":                 rs.randInt,
+		"rand_string":              rs.randString,
+		"rand_float":               rs.randFloat,
+		"rand_datum_hash":          rs.randDatumHash,
+		"rand_address":             rs.randAddressPattern,
+		"rand_address_weighted":    rs.randAddressPatternWeighted,
+		"rand_credential":          rs.randCredentialPattern,
+		"rand_credential_weighted": rs.randCredentialPatternWeighted,
+		"rand_asset":               rs.randAssetPattern,
+		"rand_asset_weighted":      rs.randAssetPatternWeighted,
+		"rand_output_ref":          rs.randOutputReferencePattern,
+		"rand_metadata_tag":        rs.randMetadataTagPattern,
+		"weighted_choice":          rs.weightedChoice,
+		"rand_datum_cbor":          rs.randDatumCBOR,
+		"rand_tx_bytes":            rs.randTxBytes,
+		"hex_to_bytes":             hexToBytes,
+	}
 }
 
-func randFloat(from float64, to float64) interface{} {
-	return (rand.Float64() * (to - from)) + from
+func (rs *randSource) randInt(from int, to int) interface{} {
+	return rs.r.IntN(to-from) + from
 }
 
-func randBlake2b256() [32]byte {
+func (rs *randSource) randFloat(from float64, to float64) interface{} {
+	return (rs.r.Float64() * (to - from)) + from
+}
+
+// fillRandomBytes fills buf from the run's PRNG. math/rand/v2's Rand has no Read
+// method, so bytes are drawn eight at a time from Uint64.
+func (rs *randSource) fillRandomBytes(buf []byte) {
+	for i := 0; i < len(buf); i += 8 {
+		v := rs.r.Uint64()
+		for j := 0; j < 8 && i+j < len(buf); j++ {
+			buf[i+j] = byte(v >> (8 * j))
+		}
+	}
+}
+
+func (rs *randSource) randBlake2b256() [32]byte {
 	// Make a buffer with the size of 128 bytes.
 	// The generator will fill it with random junk.
 	buf := make([]byte, 128)
-	_, err := rand.Read(buf)
-	if err != nil {
-		log.Printf("Error while generating random bytes: %s", err)
-		// Fill it with zero bytes.
-		buf = make([]byte, 128)
-	}
+	rs.fillRandomBytes(buf)
 	return blake2b.Sum256(buf)
 }
 
-func randBlake2b256Hex() string {
-	return fmt.Sprintf("%x", randBlake2b256())
+func (rs *randSource) randBlake2b256Hex() string {
+	return fmt.Sprintf("%x", rs.randBlake2b256())
 }
 
-func randBlake2b256Bench32() string {
-	bs := randBlake2b256()
+func (rs *randSource) randBlake2b256Bench32() string {
+	bs := rs.randBlake2b256()
 	str, _ := bech32.Encode("ed25519_pk", bs[:])
 	return str
 }
 
-func randDatumHash() interface{} {
-	return randBlake2b256Hex()
+func (rs *randSource) randDatumHash() interface{} {
+	return rs.randBlake2b256Hex()
+}
+
+// namedSource pairs a rand_*-style alternative's label with its generator. Alternative
+// sets are built as slices rather than map[string]func() string so pickUniform samples
+// them in a fixed order: map iteration order is randomized per call and is not derived
+// from the seeded PRNG, so building the weighted list by ranging over a map would make
+// the same -seed land on a different candidate depending on map order alone, breaking
+// the replay guarantee SetSeed promises.
+type namedSource struct {
+	label string
+	value func() string
 }
 
-func randAddressPattern() interface{} {
-	return shuffleSources([]func() string{
-		func() string { return "addr1" + randBlake2b256Bench32() },
-		func() string { return "stake1" + randBlake2b256Bench32() },
-		func() string { return "*" },
-	})
+// sourcesLookup builds a label->generator map for pickLabelled, which already iterates
+// its caller-supplied alternatives in a fixed order and only needs this for lookup.
+func sourcesLookup(sources []namedSource) map[string]func() string {
+	out := make(map[string]func() string, len(sources))
+	for _, s := range sources {
+		out[s.label] = s.value
+	}
+	return out
 }
 
-func randCredentialPattern() interface{} {
-	sources := []func() string{
-		func() string { return randHexString(64) },
-		func() string { return randHexString(56) },
-		func() string { return "*" },
+// addressSources maps each rand_address alternative to its label, so both the plain
+// (uniform) and _weighted builtins share one definition of "what an address can be".
+func (rs *randSource) addressSources() []namedSource {
+	return []namedSource{
+		{"addr1", func() string { return "addr1" + rs.randBlake2b256Bench32() }},
+		{"stake1", func() string { return "stake1" + rs.randBlake2b256Bench32() }},
+		{"*", func() string { return "*" }},
 	}
-	return shuffleSources(sources) + "/" + shuffleSources(sources)
 }
 
-func randPolicyIDPattern() string {
-	return shuffleSources([]func() string{
-		func() string { return randHexString(56) },
-		func() string { return "*" },
-	})
+func (rs *randSource) randAddressPattern() interface{} {
+	return rs.pickUniform(rs.addressSources(), "rand_address")
 }
 
-func randAssetNamePattern() string {
-	sources := []func() string{func() string { return "*" }}
+func (rs *randSource) randAddressPatternWeighted(alternatives ...string) (interface{}, error) {
+	return rs.pickLabelled(rs.addressSources(), alternatives)
+}
+
+func (rs *randSource) credentialSources() []namedSource {
+	return []namedSource{
+		{"key", func() string { return rs.randHexString(64) }},
+		{"script", func() string { return rs.randHexString(56) }},
+		{"*", func() string { return "*" }},
+	}
+}
 
+func (rs *randSource) randCredentialPattern() interface{} {
+	sources := rs.credentialSources()
+	return rs.pickUniform(sources, "rand_credential") + "/" + rs.pickUniform(sources, "rand_credential")
+}
+
+func (rs *randSource) randCredentialPatternWeighted(alternatives ...string) (interface{}, error) {
+	sources := rs.credentialSources()
+	first, err := rs.pickLabelled(sources, alternatives)
+	if err != nil {
+		return nil, err
+	}
+	second, err := rs.pickLabelled(sources, alternatives)
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Sprintf("%s/%s", first, second), nil
+}
+
+func (rs *randSource) randPolicyIDPattern() string {
+	return rs.pickUniform([]namedSource{
+		{"policy", func() string { return rs.randHexString(56) }},
+		{"*", func() string { return "*" }},
+	}, "")
+}
+
+func (rs *randSource) randAssetNamePattern() string {
+	sources := make([]namedSource, 0, 66)
 	for i := 0; i <= 64; i++ {
-		sources = append(sources, func() string { return randHexString(i) })
+		i := i
+		sources = append(sources, namedSource{fmt.Sprintf("len%d", i), func() string { return rs.randHexString(i) }})
 	}
+	sources = append(sources, namedSource{"*", func() string { return "*" }})
+
+	return rs.pickUniform(sources, "")
+}
 
-	return shuffleSources(sources)
+func (rs *randSource) randAssetPattern() interface{} {
+	return rs.pickUniform(rs.assetSources(), "rand_asset")
 }
 
-func randAssetPattern() interface{} {
-	return randPolicyIDPattern() + "." + randAssetNamePattern()
+// assetSources is the label set accepted by rand_asset and rand_asset_weighted: either
+// a freshly generated policy/asset-name pair, or a wildcard.
+func (rs *randSource) assetSources() []namedSource {
+	return []namedSource{
+		{"policy", func() string { return rs.randPolicyIDPattern() + "." + rs.randAssetNamePattern() }},
+		{"*", func() string { return "*" }},
+	}
+}
+
+func (rs *randSource) randAssetPatternWeighted(alternatives ...string) (interface{}, error) {
+	return rs.pickLabelled(rs.assetSources(), alternatives)
 }
 
-func randOutputIndex() string {
-	return shuffleSources([]func() string{
-		func() string {
-			randDigit := func() int { return rand.Intn(10) }
+func (rs *randSource) randOutputIndex() string {
+	return rs.pickUniform([]namedSource{
+		{"index", func() string {
+			randDigit := func() int { return rs.r.IntN(10) }
 			return fmt.Sprint(randDigit()*100 + randDigit()*10 + randDigit())
-		},
-		func() string { return "*" },
-	})
+		}},
+		{"*", func() string { return "*" }},
+	}, "")
 }
 
-func randTransactionId() string {
-	return randHexString(64)
+func (rs *randSource) randTransactionId() string {
+	return rs.randHexString(64)
 }
 
-func randOutputReferencePattern() string {
-	return randOutputIndex() + "@" + randTransactionId()
+func (rs *randSource) randOutputReferencePattern() string {
+	return rs.randOutputIndex() + "@" + rs.randTransactionId()
 }
 
-func randMetadataTagPattern() string {
-	return "{" + fmt.Sprint(rand.Intn(9999)) + "}"
+func (rs *randSource) randMetadataTagPattern() string {
+	return "{" + fmt.Sprint(rs.r.IntN(9999)) + "}"
 }
 
-func shuffleSources(sources []func() string) string {
-	n := len(sources)
-	if n == 0 {
+// pickUniform picks uniformly among sources, falling back to rs.defaultWeights[builtin]
+// when the config supplies one, so a run can bias e.g. rand_address towards concrete
+// addresses without every template spelling out rand_address_weighted by hand. builtin
+// may be "" for helpers with no corresponding config entry.
+func (rs *randSource) pickUniform(sources []namedSource, builtin string) string {
+	weights := rs.defaultWeights[builtin]
+	weighted := make([]weightedSource, 0, len(sources))
+	for _, s := range sources {
+		weight := 1.0
+		if w, ok := weights[s.label]; ok {
+			weight = w
+		}
+		weighted = append(weighted, weightedSource{value: s.value, weight: weight})
+	}
+	return rs.pickWeighted(weighted)
+}
+
+// pickLabelled parses "label:weight" alternatives (as accepted by the *_weighted
+// builtins) against a builtin's known label->generator set and samples one.
+func (rs *randSource) pickLabelled(sources []namedSource, alternatives []string) (string, error) {
+	lookup := sourcesLookup(sources)
+	weighted := make([]weightedSource, 0, len(alternatives))
+	for _, alt := range alternatives {
+		label, weight, err := parseWeightedAlternative(alt)
+		if err != nil {
+			return "", err
+		}
+		value, ok := lookup[label]
+		if !ok {
+			return "", errors.Errorf("unknown alternative %q", label)
+		}
+		weighted = append(weighted, weightedSource{value: value, weight: weight})
+	}
+	return rs.pickWeighted(weighted), nil
+}
+
+// weightedChoice is the generic `weighted_choice "a:70" "b:20" "c:10"` builtin: it
+// returns one of the literal alternatives, picked by weight, with no generator lookup.
+func (rs *randSource) weightedChoice(alternatives ...string) (interface{}, error) {
+	weighted := make([]weightedSource, 0, len(alternatives))
+	for _, alt := range alternatives {
+		label, weight, err := parseWeightedAlternative(alt)
+		if err != nil {
+			return nil, err
+		}
+		weighted = append(weighted, weightedSource{value: func() string { return label }, weight: weight})
+	}
+	return rs.pickWeighted(weighted), nil
+}
+
+func parseWeightedAlternative(alt string) (label string, weight float64, err error) {
+	idx := strings.LastIndex(alt, ":")
+	if idx < 0 {
+		return "", 0, errors.Errorf("alternative %q must be of the form \"label:weight\"", alt)
+	}
+	label = alt[:idx]
+	weight, err = strconv.ParseFloat(alt[idx+1:], 64)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "invalid weight in alternative %q", alt)
+	}
+	return label, weight, nil
+}
+
+// weightedSource is one candidate for pickWeighted: a lazily-evaluated generator and
+// its relative weight.
+type weightedSource struct {
+	value  func() string
+	weight float64
+}
+
+// pickWeighted samples sources by cumulative weight. n is always a handful of
+// alternatives per builtin, so a linear scan beats maintaining a sorted index.
+func (rs *randSource) pickWeighted(sources []weightedSource) string {
+	total := 0.0
+	for _, s := range sources {
+		total += s.weight
+	}
+	if total <= 0 {
 		return ""
 	}
-	swap := func(i, j int) { sources[i], sources[j] = sources[j], sources[i] }
-	rand.Shuffle(n, swap)
-	return sources[0]()
+	target := rs.r.Float64() * total
+	for _, s := range sources {
+		if target < s.weight {
+			return s.value()
+		}
+		target -= s.weight
+	}
+	return sources[len(sources)-1].value()
 }
 
-func randStringWithAlphabet(alphabet []rune, length int) string {
+func (rs *randSource) randStringWithAlphabet(alphabet []rune, length int) string {
 	b := make([]rune, length)
 	for i := range b {
-		b[i] = alphabet[rand.Intn(len(alphabet))]
+		b[i] = alphabet[rs.r.IntN(len(alphabet))]
 	}
 	return string(b)
 }
 
-func randHexString(length int) string {
-	return randStringWithAlphabet(
-		[]rune("abcdefABCDEF0123456789"), length)
+func (rs *randSource) randHexString(length int) string {
+	return rs.randStringWithAlphabet([]rune("abcdefABCDEF0123456789"), length)
 }
 
-func randString(length int) interface{} {
-	return randStringWithAlphabet(
-		[]rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"),
-		length)
+func (rs *randSource) randString(length int) interface{} {
+	return rs.randStringWithAlphabet(
+		[]rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"), length)
 }
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
-func parseRenderer(in interface{}) (renderer, error) {
+func parseRenderer(in interface{}, rs *randSource) (renderer, error) {
 	if in == nil {
 		return nil, nil
 	}
@@ -162,7 +326,7 @@ func parseRenderer(in interface{}) (renderer, error) {
 	case map[string]interface{}:
 		out := mapR{}
 		for k, v := range in {
-			p, err := parseRenderer(v)
+			p, err := parseRenderer(v, rs)
 			if err != nil {
 				return nil, err
 			}
@@ -172,7 +336,7 @@ func parseRenderer(in interface{}) (renderer, error) {
 	case []interface{}:
 		out := sliceR{}
 		for _, v := range in {
-			p, err := parseRenderer(v)
+			p, err := parseRenderer(v, rs)
 			if err != nil {
 				return nil, err
 			}
@@ -180,7 +344,7 @@ func parseRenderer(in interface{}) (renderer, error) {
 		}
 		return out, nil
 	case string:
-		tmpl, err := template.New("t").Funcs(builtins).Parse(in)
+		tmpl, err := template.New("t").Funcs(newBuiltins(rs)).Parse(in)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
@@ -192,6 +356,35 @@ func parseRenderer(in interface{}) (renderer, error) {
 	}
 }
 
+// ParsePayload builds the renderer for a config's payload section. A top-level
+// "encoding": "cbor" key switches the whole payload to canonical CBOR output instead of
+// the default JSON-shaped tree.
+func ParsePayload(in interface{}, rs *randSource) (renderer, error) {
+	if m, ok := in.(map[string]interface{}); ok {
+		if encoding, ok := m["encoding"]; ok {
+			body := map[string]interface{}{}
+			for k, v := range m {
+				if k != "encoding" {
+					body[k] = v
+				}
+			}
+			switch encoding {
+			case "cbor":
+				inner, err := parseRenderer(body, rs)
+				if err != nil {
+					return nil, err
+				}
+				return cborR{inner}, nil
+			case "json", nil:
+				return parseRenderer(body, rs)
+			default:
+				return nil, errors.Errorf("unsupported payload encoding %q", encoding)
+			}
+		}
+	}
+	return parseRenderer(in, rs)
+}
+
 type renderer interface {
 	render(data map[string]string) (interface{}, error)
 }
@@ -0,0 +1,41 @@
+// Package dummyworker implements a worker that only logs what it would have sent,
+// for dry-running a config file's template/payload machinery without a real backend.
+package dummyworker
+
+import (
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/chfanghr/blast-kupo/blaster"
+)
+
+// Worker logs each rendered payload instead of sending it anywhere, recording every
+// call as a success, with the time spent logging as its latency, under its configured
+// stats endpoint name.
+type Worker struct {
+	name  string
+	stats *blaster.Stats
+}
+
+// New builds a dummy Worker from config, matching blaster.WorkerConstructor.
+func New(config map[string]interface{}, stats *blaster.Stats, _ *rand.Rand) (blaster.Worker, error) {
+	name, _ := config["name"].(string)
+	if name == "" {
+		name = "dummy"
+	}
+	return &Worker{name: name, stats: stats}, nil
+}
+
+// Send logs data["payload"].
+func (w *Worker) Send(data map[string]string) error {
+	start := time.Now()
+	log.Printf("dummy: %s", data["payload"])
+	w.stats.Endpoint(w.name).Record(time.Since(start), nil)
+	return nil
+}
+
+// Close implements blaster.Worker. There's nothing to release.
+func (w *Worker) Close() error {
+	return nil
+}
@@ -0,0 +1,139 @@
+// Package gcsworker writes templated payloads as objects to one or more Google Cloud
+// Storage buckets. It shares its endpoint rotation/failover policy with httpworker via
+// blaster.Router.
+package gcsworker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+
+	"github.com/chfanghr/blast-kupo/blaster"
+)
+
+// BucketConfig is one bucket the worker may route writes to.
+type BucketConfig struct {
+	Bucket string  `json:"bucket"`
+	Prefix string  `json:"prefix"`
+	Weight float64 `json:"weight"`
+}
+
+// Config is the `worker` section of a config file with `"type": "gcs"`.
+type Config struct {
+	// Buckets is a shorthand for Endpoints when no per-bucket weight/prefix is needed.
+	Buckets []string `json:"buckets"`
+	// Endpoints is the full bucket list; set it instead of Buckets to give weights.
+	Endpoints []BucketConfig `json:"endpoints"`
+
+	Strategy blaster.Strategy `json:"strategy"`
+
+	// Cooldown is how long a bucket is skipped after a write error. Defaults to 5s.
+	Cooldown time.Duration `json:"cooldown"`
+}
+
+// Worker writes templated payloads as objects to one of several GCS buckets, rotating
+// or failing over between them (see blaster.Router) and reporting per-bucket counters
+// into stats.
+type Worker struct {
+	client       *storage.Client
+	cooldown     time.Duration
+	router       *blaster.Router
+	prefixByName map[string]string
+	rng          *rand.Rand
+	stats        *blaster.Stats
+
+	mu  sync.Mutex
+	seq uint64
+}
+
+// New builds a gcs Worker from config, matching blaster.WorkerConstructor.
+func New(config map[string]interface{}, stats *blaster.Stats, rng *rand.Rand) (blaster.Worker, error) {
+	var cfg Config
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	endpoints := make([]*blaster.Endpoint, 0, len(cfg.Buckets)+len(cfg.Endpoints))
+	prefixByName := map[string]string{}
+	for _, b := range cfg.Buckets {
+		endpoints = append(endpoints, blaster.NewEndpoint(b, 1))
+	}
+	for _, e := range cfg.Endpoints {
+		endpoints = append(endpoints, blaster.NewEndpoint(e.Bucket, e.Weight))
+		prefixByName[e.Bucket] = e.Prefix
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("gcsworker: config must set buckets or endpoints")
+	}
+
+	strategy := cfg.Strategy
+	if strategy == "" {
+		strategy = blaster.StrategyRoundRobin
+	}
+
+	cooldown := cfg.Cooldown
+	if cooldown <= 0 {
+		cooldown = 5 * time.Second
+	}
+
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &Worker{
+		client:       client,
+		cooldown:     cooldown,
+		router:       &blaster.Router{Strategy: strategy, Endpoints: endpoints},
+		prefixByName: prefixByName,
+		rng:          rng,
+		stats:        stats,
+	}, nil
+}
+
+// Send writes data["payload"] as an object to one bucket, chosen by the router per the
+// worker's strategy (using data["key"] for sticky_by_key), and records the outcome
+// under that bucket's stats.
+func (w *Worker) Send(data map[string]string) error {
+	now := time.Now()
+	ep := w.router.Pick(now, data["key"], w.rng)
+	if ep == nil {
+		return errors.New("gcsworker: no healthy bucket available")
+	}
+
+	start := time.Now()
+	obj := w.client.Bucket(ep.Name).Object(fmt.Sprintf("%s%d", w.prefixByName[ep.Name], w.nextSeq()))
+	writer := obj.NewWriter(context.Background())
+	_, err := writer.Write([]byte(data["payload"]))
+	if err == nil {
+		err = writer.Close()
+	}
+	latency := time.Since(start)
+	if err != nil {
+		ep.MarkUnhealthy(now, w.cooldown)
+	}
+	w.stats.Endpoint(ep.Name).Record(latency, err)
+	return err
+}
+
+// Close releases the underlying GCS client.
+func (w *Worker) Close() error {
+	return w.client.Close()
+}
+
+func (w *Worker) nextSeq() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.seq++
+	return w.seq
+}
@@ -0,0 +1,137 @@
+// Package httpworker sends templated requests over plain HTTP. It is the default
+// worker type for exercising Kupo's /matches API.
+package httpworker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/chfanghr/blast-kupo/blaster"
+)
+
+// EndpointConfig is one backend the worker may route requests to.
+type EndpointConfig struct {
+	URL    string  `json:"url"`
+	Weight float64 `json:"weight"`
+}
+
+// Config is the `worker` section of a config file with `"type": "http"`.
+type Config struct {
+	// URLs is a shorthand for Endpoints when no per-endpoint weight is needed.
+	URLs []string `json:"urls"`
+	// Endpoints is the full endpoint list; set it instead of URLs to give weights.
+	Endpoints []EndpointConfig `json:"endpoints"`
+
+	Strategy blaster.Strategy `json:"strategy"`
+	Method   string           `json:"method"`
+
+	// Cooldown is how long an endpoint is skipped after a 5xx or connection error.
+	// Defaults to 5s.
+	Cooldown time.Duration `json:"cooldown"`
+	Timeout  time.Duration `json:"timeout"`
+}
+
+// Worker sends templated requests to one of several HTTP endpoints, rotating or
+// failing over between them (see blaster.Router) and reporting per-endpoint counters
+// into stats.
+type Worker struct {
+	client   *http.Client
+	method   string
+	cooldown time.Duration
+	router   *blaster.Router
+	rng      *rand.Rand
+	stats    *blaster.Stats
+}
+
+// New builds an http Worker from config, matching blaster.WorkerConstructor.
+func New(config map[string]interface{}, stats *blaster.Stats, rng *rand.Rand) (blaster.Worker, error) {
+	var cfg Config
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	endpoints := make([]*blaster.Endpoint, 0, len(cfg.URLs)+len(cfg.Endpoints))
+	for _, u := range cfg.URLs {
+		endpoints = append(endpoints, blaster.NewEndpoint(u, 1))
+	}
+	for _, e := range cfg.Endpoints {
+		endpoints = append(endpoints, blaster.NewEndpoint(e.URL, e.Weight))
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("httpworker: config must set urls or endpoints")
+	}
+
+	strategy := cfg.Strategy
+	if strategy == "" {
+		strategy = blaster.StrategyRoundRobin
+	}
+
+	cooldown := cfg.Cooldown
+	if cooldown <= 0 {
+		cooldown = 5 * time.Second
+	}
+
+	method := cfg.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	return &Worker{
+		client:   &http.Client{Timeout: cfg.Timeout},
+		method:   method,
+		cooldown: cooldown,
+		router:   &blaster.Router{Strategy: strategy, Endpoints: endpoints},
+		rng:      rng,
+		stats:    stats,
+	}, nil
+}
+
+// Send sends data["payload"] to one endpoint, chosen by the router per the worker's
+// strategy (using data["key"] for sticky_by_key), and records the outcome under that
+// endpoint's stats.
+func (w *Worker) Send(data map[string]string) error {
+	now := time.Now()
+	ep := w.router.Pick(now, data["key"], w.rng)
+	if ep == nil {
+		return errors.New("httpworker: no healthy endpoint available")
+	}
+
+	start := time.Now()
+	req, err := http.NewRequestWithContext(context.Background(), w.method, ep.Name, bytes.NewReader([]byte(data["payload"])))
+	if err != nil {
+		w.stats.Endpoint(ep.Name).Record(time.Since(start), err)
+		return errors.WithStack(err)
+	}
+
+	resp, err := w.client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		ep.MarkUnhealthy(now, w.cooldown)
+		w.stats.Endpoint(ep.Name).Record(latency, err)
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		ep.MarkUnhealthy(now, w.cooldown)
+		err = errors.Errorf("httpworker: %s returned %s", ep.Name, resp.Status)
+	}
+	w.stats.Endpoint(ep.Name).Record(latency, err)
+	return err
+}
+
+// Close implements blaster.Worker. The worker keeps no persistent connections beyond
+// what http.Client pools, so there's nothing to release.
+func (w *Worker) Close() error {
+	return nil
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/chfanghr/blast-kupo/dummyworker"
 	"github.com/chfanghr/blast-kupo/gcsworker"
 	"github.com/chfanghr/blast-kupo/httpworker"
+	"github.com/chfanghr/blast-kupo/ogmiosworker"
 )
 
 // Set debug to true to dump full stack info on every error.
@@ -33,6 +34,7 @@ func main() {
 	b.RegisterWorkerType("dummy", dummyworker.New)
 	b.RegisterWorkerType("http", httpworker.New)
 	b.RegisterWorkerType("gcs", gcsworker.New)
+	b.RegisterWorkerType("ogmios", ogmiosworker.New)
 
 	if err := b.Command(ctx); err != nil {
 		if debug {
@@ -0,0 +1,277 @@
+// Package ogmiosworker sends templated JSON-RPC 2.0 requests over a persistent Ogmios
+// WebSocket connection, correlating responses by id and pipelining multiple in-flight
+// requests per connection as Ogmios's chainsync/state-query/tx-submission protocols
+// allow.
+package ogmiosworker
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+
+	"github.com/chfanghr/blast-kupo/blaster"
+)
+
+// Config is the `worker` section of a config file with `"type": "ogmios"`.
+type Config struct {
+	// URL is the Ogmios WebSocket endpoint, e.g. "ws://localhost:1337".
+	URL string `json:"url"`
+
+	// MaxInFlight caps how many requests this connection will pipeline at once; Send
+	// blocks once that many replies are still outstanding. Defaults to 16.
+	MaxInFlight int `json:"max_in_flight"`
+
+	// Method is the JSON-RPC method to call for every Send, e.g.
+	// "queryLedgerState/utxo".
+	Method string `json:"method"`
+
+	// ReconnectBackoff is the delay after the first failed redial of a dropped
+	// connection (the first redial itself is immediate); it doubles on each further
+	// failure up to MaxReconnectBackoff. Defaults to 100ms/5s.
+	ReconnectBackoff    time.Duration `json:"reconnect_backoff"`
+	MaxReconnectBackoff time.Duration `json:"max_reconnect_backoff"`
+}
+
+type request struct {
+	Jsonrpc string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	ID      uint64      `json:"id"`
+}
+
+type response struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+// reply is what a pending Send call waits on: either the correlated response, or err
+// set if the connection dropped before a response for this id ever arrived.
+type reply struct {
+	resp response
+	err  error
+}
+
+// Worker sends one JSON-RPC 2.0 request per Send over a persistent, reconnecting
+// Ogmios WebSocket connection, pipelining up to MaxInFlight requests at once instead of
+// waiting for each reply before writing the next.
+type Worker struct {
+	url         string
+	method      string
+	maxInFlight chan struct{}
+
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	mu       sync.Mutex
+	conn     *websocket.Conn
+	nextID   uint64
+	pending  map[uint64]chan reply
+	closed   bool
+	stats    *blaster.Stats
+	readLoop sync.WaitGroup
+	inFlight sync.WaitGroup
+}
+
+// New builds an ogmios Worker from config, matching blaster.WorkerConstructor.
+func New(config map[string]interface{}, stats *blaster.Stats, _ *rand.Rand) (blaster.Worker, error) {
+	var cfg Config
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if cfg.URL == "" {
+		return nil, errors.New("ogmiosworker: config must set url")
+	}
+	if cfg.Method == "" {
+		return nil, errors.New("ogmiosworker: config must set method")
+	}
+
+	maxInFlight := cfg.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = 16
+	}
+	backoff := cfg.ReconnectBackoff
+	if backoff <= 0 {
+		backoff = 100 * time.Millisecond
+	}
+	maxBackoff := cfg.MaxReconnectBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 5 * time.Second
+	}
+
+	w := &Worker{
+		url:         cfg.URL,
+		method:      cfg.Method,
+		maxInFlight: make(chan struct{}, maxInFlight),
+		backoff:     backoff,
+		maxBackoff:  maxBackoff,
+		pending:     map[uint64]chan reply{},
+		stats:       stats,
+	}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// connect dials the Ogmios endpoint and starts the reader goroutine that demultiplexes
+// replies back to their caller by id.
+func (w *Worker) connect() error {
+	conn, _, err := websocket.DefaultDialer.Dial(w.url, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	w.mu.Lock()
+	w.conn = conn
+	w.mu.Unlock()
+
+	w.readLoop.Add(1)
+	go w.readReplies(conn)
+	return nil
+}
+
+func (w *Worker) readReplies(conn *websocket.Conn) {
+	defer w.readLoop.Done()
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			// Every request still waiting on this connection will never see its
+			// reply; fail them immediately instead of leaving Send blocked forever.
+			w.failPending(errors.Wrap(err, "ogmiosworker: connection closed"))
+			w.reconnect()
+			return
+		}
+		var resp response
+		if err := json.Unmarshal(data, &resp); err != nil {
+			continue
+		}
+		w.mu.Lock()
+		ch, ok := w.pending[resp.ID]
+		if ok {
+			delete(w.pending, resp.ID)
+		}
+		w.mu.Unlock()
+		if ok {
+			ch <- reply{resp: resp}
+		}
+	}
+}
+
+// failPending delivers err to every Send call still waiting for a reply on this
+// connection, so none of them block forever across a reconnect.
+func (w *Worker) failPending(err error) {
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = map[uint64]chan reply{}
+	w.mu.Unlock()
+
+	for _, ch := range pending {
+		ch <- reply{err: err}
+	}
+}
+
+// reconnect redials with exponential backoff, unless the worker has been closed.
+func (w *Worker) reconnect() {
+	delay := w.backoff
+	for {
+		w.mu.Lock()
+		closed := w.closed
+		w.mu.Unlock()
+		if closed {
+			return
+		}
+		if err := w.connect(); err == nil {
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+		if delay > w.maxBackoff {
+			delay = w.maxBackoff
+		}
+	}
+}
+
+// Send issues one JSON-RPC 2.0 call whose params is data["payload"] parsed back into a
+// JSON value, and returns as soon as it's written. A payload that isn't JSON (such as a
+// cbor-encoded one, which blaster.Command passes through as raw bytes) is an error. Send only blocks once MaxInFlight requests are
+// already outstanding: blaster.Command's loop is otherwise strictly sequential, so
+// waiting for the correlated reply here would make MaxInFlight and the reader
+// goroutine's id-correlation dead code and collapse the connection to one
+// request-at-a-time. Outcomes for replies that arrive (or fail to) after Send returns
+// are reported asynchronously via stats.
+func (w *Worker) Send(data map[string]string) error {
+	var params interface{}
+	if payload := data["payload"]; payload != "" {
+		if err := json.Unmarshal([]byte(payload), &params); err != nil {
+			return errors.Wrap(err, "ogmiosworker: payload is not valid JSON")
+		}
+	}
+
+	w.maxInFlight <- struct{}{}
+
+	w.mu.Lock()
+	w.nextID++
+	id := w.nextID
+	replyCh := make(chan reply, 1)
+	w.pending[id] = replyCh
+	conn := w.conn
+	w.mu.Unlock()
+
+	start := time.Now()
+	req := request{Jsonrpc: "2.0", Method: w.method, Params: params, ID: id}
+	if err := conn.WriteJSON(req); err != nil {
+		w.mu.Lock()
+		delete(w.pending, id)
+		w.mu.Unlock()
+		<-w.maxInFlight
+		w.stats.Endpoint(w.url).Record(time.Since(start), err)
+		return errors.WithStack(err)
+	}
+
+	w.inFlight.Add(1)
+	go w.awaitReply(replyCh, start)
+	return nil
+}
+
+// awaitReply blocks for one request's correlated reply (or its failPending error) and
+// records the round trip under the worker's stats, off of Send's call stack so several
+// requests can be outstanding at once.
+func (w *Worker) awaitReply(replyCh chan reply, start time.Time) {
+	defer w.inFlight.Done()
+	defer func() { <-w.maxInFlight }()
+
+	r := <-replyCh
+	err := r.err
+	if err == nil && len(r.resp.Error) > 0 {
+		err = errors.Errorf("ogmiosworker: %s", r.resp.Error)
+	}
+	w.stats.Endpoint(w.url).Record(time.Since(start), err)
+}
+
+// Close stops accepting new replies, waits for every in-flight request to be accounted
+// for (successfully, or failed via failPending once the connection drops), and stops
+// the reader goroutine.
+func (w *Worker) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	conn := w.conn
+	w.mu.Unlock()
+
+	var err error
+	if conn != nil {
+		err = conn.Close()
+	}
+	w.inFlight.Wait()
+	w.readLoop.Wait()
+	return err
+}